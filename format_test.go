@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func numbers(n int) []interface{} {
+	a := make([]interface{}, n)
+	for i := range a {
+		a[i] = json.Number(fmt.Sprintf("%d", i))
+	}
+
+	return a
+}
+
+func TestFormatArrayFillMode(t *testing.T) {
+	got, err := formatArray(numbers(10), 20, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n  0, 1, 2, 3, 4, 5,\n  6, 7, 8, 9\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
+
+func TestFormatArrayNarrowWidth(t *testing.T) {
+	// A width this narrow leaves room to pack only three short elements
+	// per line.
+	got, err := formatArray(numbers(10), 10, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n  0, 1, 2,\n  3, 4, 5,\n  6, 7, 8,\n  9\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
+
+func TestFormatArrayWidthBoundary(t *testing.T) {
+	// width is exactly wide enough for "0," alone, but not "0, 1,", so the
+	// second element must wrap rather than overrun.
+	small := []interface{}{json.Number("0"), json.Number("1"), json.Number("2")}
+
+	got, err := formatArray(small, 6, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n  0,\n  1, 2\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
+
+func TestFormatArrayVerticalFallback(t *testing.T) {
+	// An element too wide to fit on a line of its own (even alone) falls
+	// back to its full recursive layout instead of being packed.
+	mixed := []interface{}{
+		orderedObject{{Key: "a", Value: json.Number("1")}},
+		json.Number("2"),
+		json.Number("3"),
+		"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx",
+	}
+
+	got, err := formatArray(mixed, 20, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n  {\"a\": 1}, 2, 3,\n  \"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx\"\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
+
+func TestFormatArrayNestedArrayFallback(t *testing.T) {
+	// A nested array that doesn't fit on one line recurses into its own
+	// fill-mode layout (at the deeper indent level) rather than being
+	// flattened into the parent's.
+	arr := []interface{}{numbers(8), json.Number("9")}
+
+	got, err := formatArray(arr, 20, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n  [\n    0, 1, 2, 3, 4,\n    5, 6, 7\n  ],\n  9\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
+
+func TestFormatArrayEmpty(t *testing.T) {
+	got, err := formatArray(nil, 20, 0)
+	if err != nil {
+		t.Fatalf("formatArray: %v", err)
+	}
+
+	want := "[\n]"
+	if string(got) != want {
+		t.Errorf("formatArray = %q, want %q", got, want)
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -16,6 +18,10 @@ var (
 	width      = flag.Uint("width", 80, "Soft width limit for output.")
 	indent     = flag.Uint("indent", 2, "Indent width.")
 	sortKeys   = flag.Bool("sort_keys", false, "Sort object keys lexicographically.")
+	stream     = flag.Bool("stream", false, "Decode and format successive top-level values instead of a single document.")
+	delimiter  = flag.String("delimiter", "\n", "Delimiter written between values in -stream mode.")
+	query      = flag.String("query", "", "jq-style path query to project before formatting, e.g. .foo.bar[0] or .items[].name.")
+	relaxed    = flag.Bool("relaxed", false, "Tolerate comments, trailing commas, unquoted keys and single-quoted strings in the input.")
 )
 
 func main() {
@@ -41,16 +47,205 @@ func main() {
 	}
 	defer output.Close()
 
-	var v interface{}
-	if err := json.NewDecoder(input).Decode(&v); err != nil {
+	if *stream && *query != "" {
+		panic(fmt.Errorf("-query is not supported together with -stream"))
+	}
+
+	src := io.Reader(input)
+	if *relaxed {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			panic(err)
+		}
+
+		strict, err := relaxedToStrictJSON(data)
+		if err != nil {
+			panic(err)
+		}
+
+		src = bytes.NewReader(strict)
+	}
+
+	if *stream {
+		if err := encodeJSONStream(src, int(*width), output); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+
+	v, err := decodeValue(dec)
+	if err != nil {
 		panic(err)
 	}
 
+	if *query != "" {
+		if err := encodeJSONQuery(v, *query, int(*width), output); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
 	if err := encodeJSON(v, int(*width), output); err != nil {
 		panic(err)
 	}
 }
 
+// encodeJSONQuery evaluates a jq-style path query against v and formats the
+// result. Queries containing a `[]` iteration step produce a stream of
+// values, each formatted separately and separated by *delimiter (as in
+// -stream mode); all other queries produce a single formatted value.
+func encodeJSONQuery(v interface{}, query string, width int, output io.Writer) error {
+	segs, err := parsePath(query)
+	if err != nil {
+		return err
+	}
+
+	values, isStream, err := evalPath([]interface{}{v}, segs)
+	if err != nil {
+		return err
+	}
+
+	if !isStream {
+		return encodeJSON(values[0], width, output)
+	}
+
+	for i, item := range values {
+		if i > 0 {
+			if _, err := io.WriteString(output, *delimiter); err != nil {
+				return err
+			}
+		}
+
+		if err := encodeJSON(item, width, output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeJSONStream reads successive top-level JSON values from input and
+// formats each one independently, writing them to output separated by
+// *delimiter. This allows pj to process NDJSON and concatenated JSON value
+// streams without buffering the whole input.
+func encodeJSONStream(input io.Reader, width int, output io.Writer) error {
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+
+	first := true
+	for {
+		v, err := decodeValue(dec)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if !first {
+			if _, err := io.WriteString(output, *delimiter); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if err := encodeJSON(v, width, output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// orderedObjectEntry is a single key/value pair of an orderedObject,
+// preserving the position it appeared in within the source document.
+type orderedObjectEntry struct {
+	Key   string
+	Value interface{}
+}
+
+// orderedObject holds the fields of a JSON object in source order, so that
+// formatting an already-parsed document doesn't randomise field order the
+// way map[string]interface{} does.
+type orderedObject []orderedObjectEntry
+
+// decodeValue reads one top-level JSON value from dec using its Token
+// stream, building []interface{} for arrays and orderedObject for objects
+// so that object key order survives the round trip through formatIndent.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeValueToken(dec, tok)
+}
+
+func decodeValueToken(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := orderedObject{}
+
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			valTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := decodeValueToken(dec, valTok)
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, orderedObjectEntry{Key: keyTok.(string), Value: val})
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return obj, nil
+	case '[':
+		arr := []interface{}{}
+
+		for dec.More() {
+			valTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := decodeValueToken(dec, valTok)
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, val)
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return arr, nil
+	}
+
+	return nil, fmt.Errorf("unexpected delimiter %q", delim)
+}
+
 func encodeJSON(v interface{}, width int, output io.Writer) error {
 	d, err := formatIndent(v, width, 0, 0)
 	if err != nil {
@@ -75,12 +270,12 @@ func formatIndent(v interface{}, width, level, additional int) ([]byte, error) {
 		return []byte("null"), nil
 	}
 
-	switch v.(type) {
+	switch v := v.(type) {
 	case string, bool:
 		return json.Marshal(v)
-	case float64:
-		return []byte(fmt.Sprintf("%.f", v)), nil
-	case []interface{}, map[string]interface{}:
+	case json.Number:
+		return []byte(v.String()), nil
+	case []interface{}, orderedObject:
 		if d, err := formatOneLine(v); err != nil {
 			return nil, err
 		} else if level*int(*indent)+additional+len(d) <= width {
@@ -91,60 +286,114 @@ func formatIndent(v interface{}, width, level, additional int) ([]byte, error) {
 			return formatArray(v, width, level)
 		}
 
-		if v, ok := v.(map[string]interface{}); ok {
-			return formatObject(v, width, level)
+		if v, ok := v.(orderedObject); ok {
+			return formatOrderedObject(v, width, level)
 		}
 	}
 
 	return nil, fmt.Errorf("couldn't encode type %T", v)
 }
 
+// formatVertical renders v in its vertical (never one-line) layout, given
+// oneLine, its already-computed formatOneLine rendering. It's used by
+// formatArray's fill mode once a value is known not to fit on a line by
+// itself, so it doesn't redundantly re-check that via formatIndent.
+func formatVertical(v interface{}, oneLine []byte, width, level int) ([]byte, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		return formatArray(v, width, level)
+	case orderedObject:
+		return formatOrderedObject(v, width, level)
+	}
+
+	return oneLine, nil
+}
+
+// formatArray lays an array out one value per line, except it greedily
+// packs consecutive short values onto the same line (fill mode) as long as
+// the line stays within width. A value that doesn't fit even on a line of
+// its own falls back to its full recursive (vertical) layout.
 func formatArray(a []interface{}, width, level int) ([]byte, error) {
 	bits := []string{"["}
 
+	indentStr := strings.Repeat(" ", (level+1)*int(*indent))
+	lineStart := (level + 1) * int(*indent)
+	lineWidth := lineStart
+
+	var line []string
+
+	flush := func() {
+		if len(line) > 0 {
+			bits = append(bits, indentStr+strings.Join(line, " "))
+			line = nil
+			lineWidth = lineStart
+		}
+	}
+
 	j := len(a)
 	for i, v := range a {
-		d, err := formatIndent(v, width, level+1, 0)
+		suffix := ""
+		if i < j-1 {
+			suffix = ","
+		}
+
+		d, err := formatOneLine(v)
 		if err != nil {
 			return nil, err
 		}
 
-		suffix := ""
-		if i < j-1 {
-			suffix = ","
+		piece := string(d) + suffix
+		cost := len(piece)
+		if len(line) > 0 {
+			cost++ // separating space
 		}
 
-		bits = append(bits, strings.Repeat(" ", (level+1)*int(*indent))+string(d)+suffix)
+		if lineWidth+cost <= width {
+			line = append(line, piece)
+			lineWidth += cost
+			continue
+		}
+
+		flush()
+
+		if lineStart+len(piece) <= width {
+			line = append(line, piece)
+			lineWidth += len(piece)
+			continue
+		}
+
+		fd, err := formatVertical(v, d, width, level+1)
+		if err != nil {
+			return nil, err
+		}
+
+		bits = append(bits, indentStr+string(fd)+suffix)
 	}
 
+	flush()
+
 	bits = append(bits, strings.Repeat(" ", level*int(*indent))+"]")
 
 	return []byte(strings.Join(bits, "\n")), nil
 }
 
-func formatObject(m map[string]interface{}, width, level int) ([]byte, error) {
+func formatOrderedObject(o orderedObject, width, level int) ([]byte, error) {
 	bits := []string{"{"}
 
-	var keys []string
-
-	for k := range m {
-		keys = append(keys, k)
-	}
-
+	entries := o
 	if *sortKeys {
-		sort.Strings(keys)
+		entries = append(orderedObject{}, o...)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
 	}
 
-	j := len(m)
-	for i, k := range keys {
-		v := m[k]
-
-		kp, err := json.Marshal(k)
+	j := len(entries)
+	for i, e := range entries {
+		kp, err := json.Marshal(e.Key)
 		if err != nil {
 			return nil, err
 		}
 
-		d, err := formatIndent(v, width, level+1, len(kp)+2)
+		d, err := formatIndent(e.Value, width, level+1, len(kp)+2)
 		if err != nil {
 			return nil, err
 		}
@@ -155,8 +404,6 @@ func formatObject(m map[string]interface{}, width, level int) ([]byte, error) {
 		}
 
 		bits = append(bits, strings.Repeat(" ", (level+1)*int(*indent))+string(kp)+": "+string(d)+suffix)
-
-		i++
 	}
 
 	bits = append(bits, strings.Repeat(" ", level*int(*indent))+"}")
@@ -172,10 +419,12 @@ func formatOneLine(v interface{}) ([]byte, error) {
 	switch v := v.(type) {
 	case string, float64, bool:
 		return json.Marshal(v)
+	case json.Number:
+		return []byte(v.String()), nil
 	case []interface{}:
 		return formatArrayOneLine(v)
-	case map[string]interface{}:
-		return formatObjectOneLine(v)
+	case orderedObject:
+		return formatOrderedObjectOneLine(v)
 	}
 
 	return nil, fmt.Errorf("can't format type %T", v)
@@ -196,28 +445,334 @@ func formatArrayOneLine(a []interface{}) ([]byte, error) {
 	return []byte("[" + strings.Join(bits, ", ") + "]"), nil
 }
 
-func formatObjectOneLine(m map[string]interface{}) ([]byte, error) {
-	bits := []string{}
+// pathSegmentKind is the kind of a single step in a -query path expression.
+type pathSegmentKind int
 
-	var keys []string
+const (
+	pathSegmentField pathSegmentKind = iota
+	pathSegmentIndex
+	pathSegmentIterate
+)
+
+// pathSegment is one step of a parsed -query expression, e.g. the `.foo`,
+// `[0]` or `[]` in `.foo[0][]`.
+type pathSegment struct {
+	kind  pathSegmentKind
+	field string
+	index int
+}
 
-	for k := range m {
-		keys = append(keys, k)
+// parsePath parses a minimal jq-style path expression supporting field
+// access (.foo), integer indexing ([0]) and array iteration ([]).
+func parsePath(query string) ([]pathSegment, error) {
+	var segs []pathSegment
+
+	i := 0
+	for i < len(query) {
+		switch {
+		case query[i] == '.':
+			i++
+		case query[i] == '[':
+			j := strings.IndexByte(query[i:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated [ in query %q", query)
+			}
+			j += i
+
+			content := query[i+1 : j]
+			i = j + 1
+
+			if content == "" {
+				segs = append(segs, pathSegment{kind: pathSegmentIterate})
+				continue
+			}
+
+			n, err := strconv.Atoi(content)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q in query %q", content, query)
+			}
+
+			segs = append(segs, pathSegment{kind: pathSegmentIndex, index: n})
+		default:
+			j := i
+			for j < len(query) && query[j] != '.' && query[j] != '[' {
+				j++
+			}
+
+			segs = append(segs, pathSegment{kind: pathSegmentField, field: query[i:j]})
+			i = j
+		}
 	}
 
-	if *sortKeys {
-		sort.Strings(keys)
+	return segs, nil
+}
+
+// evalPath applies segs to each value in values in turn, flattening the
+// results of any iterate segment into the next step's input. It reports
+// whether the query contains an iterate segment, in which case the result
+// is a stream of values rather than a single one.
+func evalPath(values []interface{}, segs []pathSegment) ([]interface{}, bool, error) {
+	isStream := false
+
+	for _, seg := range segs {
+		var next []interface{}
+
+		switch seg.kind {
+		case pathSegmentField:
+			for _, v := range values {
+				val, err := pathField(v, seg.field)
+				if err != nil {
+					return nil, false, err
+				}
+
+				next = append(next, val)
+			}
+		case pathSegmentIndex:
+			for _, v := range values {
+				val, err := pathIndex(v, seg.index)
+				if err != nil {
+					return nil, false, err
+				}
+
+				next = append(next, val)
+			}
+		case pathSegmentIterate:
+			isStream = true
+
+			for _, v := range values {
+				arr, ok := v.([]interface{})
+				if !ok {
+					return nil, false, fmt.Errorf("cannot iterate over %T", v)
+				}
+
+				next = append(next, arr...)
+			}
+		}
+
+		values = next
 	}
 
-	for _, k := range keys {
-		v := m[k]
+	return values, isStream, nil
+}
 
-		kp, err := json.Marshal(k)
+func pathField(v interface{}, field string) (interface{}, error) {
+	obj, ok := v.(orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("cannot index %T with %q", v, field)
+	}
+
+	for _, e := range obj {
+		if e.Key == field {
+			return e.Value, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func pathIndex(v interface{}, index int) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot index %T with %d", v, index)
+	}
+
+	if index < 0 || index >= len(arr) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+
+	return arr[index], nil
+}
+
+// relaxedToStrictJSON pre-processes a JSON5/JSONC-ish document into strict
+// JSON: it strips `//` and `/* */` comments, drops trailing commas before a
+// closing `}`/`]`, quotes bare identifier object keys, and rewrites
+// single-quoted strings as double-quoted ones. The output is fed into the
+// normal strict decoder, so the rest of the pipeline is unaffected.
+func relaxedToStrictJSON(src []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	n := len(src)
+	for i := 0; i < n; {
+		c := src[i]
+
+		switch {
+		case c == '/' && i+1 < n && src[i+1] == '/':
+			for i < n && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && src[i+1] == '*':
+			i += 2
+			for i+1 < n && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			if i+1 >= n {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += 2
+		case c == '\'' || c == '"':
+			s, next, err := scanRelaxedString(src, i)
+			if err != nil {
+				return nil, err
+			}
+
+			kp, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+
+			out.Write(kp)
+			i = next
+		case c == ',':
+			j := skipRelaxedFiller(src, i+1)
+			if j < n && (src[j] == '}' || src[j] == ']') {
+				i++
+			} else {
+				out.WriteByte(c)
+				i++
+			}
+		case isRelaxedIdentStart(c):
+			j := i
+			for j < n && isRelaxedIdentPart(src[j]) {
+				j++
+			}
+			word := string(src[i:j])
+			i = j
+
+			if k := skipRelaxedFiller(src, i); k < n && src[k] == ':' {
+				kp, err := json.Marshal(word)
+				if err != nil {
+					return nil, err
+				}
+
+				out.Write(kp)
+			} else {
+				out.WriteString(word)
+			}
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.Bytes(), nil
+}
+
+// skipRelaxedFiller returns the index of the next byte in src at or after i
+// that isn't whitespace or part of a `//`/`/* */` comment.
+func skipRelaxedFiller(src []byte, i int) int {
+	n := len(src)
+
+	for i < n {
+		switch {
+		case src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r':
+			i++
+		case src[i] == '/' && i+1 < n && src[i+1] == '/':
+			for i < n && src[i] != '\n' {
+				i++
+			}
+		case src[i] == '/' && i+1 < n && src[i+1] == '*':
+			i += 2
+			for i+1 < n && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			i += 2
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+func isRelaxedIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isRelaxedIdentPart(c byte) bool {
+	return isRelaxedIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// scanRelaxedString reads a single- or double-quoted string starting at
+// src[i], resolving backslash escapes, and returns its logical value along
+// with the index just past the closing quote.
+func scanRelaxedString(src []byte, i int) (string, int, error) {
+	quote := src[i]
+	i++
+
+	n := len(src)
+
+	var sb strings.Builder
+	for i < n {
+		c := src[i]
+
+		if c == quote {
+			return sb.String(), i + 1, nil
+		}
+
+		if c == '\\' && i+1 < n {
+			e := src[i+1]
+			switch e {
+			case '\'', '"', '\\', '/':
+				sb.WriteByte(e)
+				i += 2
+			case 'b':
+				sb.WriteByte('\b')
+				i += 2
+			case 'f':
+				sb.WriteByte('\f')
+				i += 2
+			case 'n':
+				sb.WriteByte('\n')
+				i += 2
+			case 'r':
+				sb.WriteByte('\r')
+				i += 2
+			case 't':
+				sb.WriteByte('\t')
+				i += 2
+			case 'u':
+				if i+6 > n {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+
+				r, err := strconv.ParseUint(string(src[i+2:i+6]), 16, 32)
+				if err != nil {
+					return "", 0, err
+				}
+
+				sb.WriteRune(rune(r))
+				i += 6
+			default:
+				sb.WriteByte(e)
+				i += 2
+			}
+
+			continue
+		}
+
+		sb.WriteByte(c)
+		i++
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func formatOrderedObjectOneLine(o orderedObject) ([]byte, error) {
+	bits := []string{}
+
+	entries := o
+	if *sortKeys {
+		entries = append(orderedObject{}, o...)
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	}
+
+	for _, e := range entries {
+		kp, err := json.Marshal(e.Key)
 		if err != nil {
 			return nil, err
 		}
 
-		d, err := formatOneLine(v)
+		d, err := formatOneLine(e.Value)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []pathSegment
+	}{
+		{".foo", []pathSegment{{kind: pathSegmentField, field: "foo"}}},
+		{".foo.bar", []pathSegment{
+			{kind: pathSegmentField, field: "foo"},
+			{kind: pathSegmentField, field: "bar"},
+		}},
+		{".foo[0]", []pathSegment{
+			{kind: pathSegmentField, field: "foo"},
+			{kind: pathSegmentIndex, index: 0},
+		}},
+		{".items[].name", []pathSegment{
+			{kind: pathSegmentField, field: "items"},
+			{kind: pathSegmentIterate},
+			{kind: pathSegmentField, field: "name"},
+		}},
+	}
+
+	for _, c := range cases {
+		got, err := parsePath(c.query)
+		if err != nil {
+			t.Errorf("parsePath(%q): unexpected error: %v", c.query, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parsePath(%q) = %+v, want %+v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestParsePathUnterminatedIndex(t *testing.T) {
+	if _, err := parsePath(".foo[0"); err == nil {
+		t.Error("parsePath(\".foo[0\"): expected error, got nil")
+	}
+}
+
+func TestEvalPathField(t *testing.T) {
+	v := orderedObject{
+		{Key: "foo", Value: orderedObject{{Key: "bar", Value: "baz"}}},
+	}
+
+	segs, err := parsePath(".foo.bar")
+	if err != nil {
+		t.Fatalf("parsePath: %v", err)
+	}
+
+	values, isStream, err := evalPath([]interface{}{v}, segs)
+	if err != nil {
+		t.Fatalf("evalPath: %v", err)
+	}
+
+	if isStream {
+		t.Error("evalPath: expected isStream = false")
+	}
+
+	if len(values) != 1 || values[0] != "baz" {
+		t.Errorf("evalPath = %+v, want [\"baz\"]", values)
+	}
+}
+
+func TestEvalPathIterate(t *testing.T) {
+	v := orderedObject{
+		{Key: "items", Value: []interface{}{
+			orderedObject{{Key: "name", Value: "a"}},
+			orderedObject{{Key: "name", Value: "b"}},
+		}},
+	}
+
+	segs, err := parsePath(".items[].name")
+	if err != nil {
+		t.Fatalf("parsePath: %v", err)
+	}
+
+	values, isStream, err := evalPath([]interface{}{v}, segs)
+	if err != nil {
+		t.Fatalf("evalPath: %v", err)
+	}
+
+	if !isStream {
+		t.Error("evalPath: expected isStream = true")
+	}
+
+	want := []interface{}{"a", "b"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("evalPath = %+v, want %+v", values, want)
+	}
+}
+
+func TestEvalPathIndexOutOfRange(t *testing.T) {
+	segs, err := parsePath("[2]")
+	if err != nil {
+		t.Fatalf("parsePath: %v", err)
+	}
+
+	if _, _, err := evalPath([]interface{}{[]interface{}{1, 2}}, segs); err == nil {
+		t.Error("evalPath: expected out-of-range error, got nil")
+	}
+}
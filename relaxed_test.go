@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRelaxedToStrictJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"line comment", "{\n  // a comment\n  \"foo\": 1\n}", `{"foo":1}`},
+		{"block comment", "{/* hi */\"foo\": 1}", `{"foo":1}`},
+		{"trailing comma object", `{"foo": 1,}`, `{"foo":1}`},
+		{"trailing comma array", `[1, 2,]`, `[1,2]`},
+		{"unquoted key", `{foo: 1}`, `{"foo":1}`},
+		{"single-quoted string", `{'foo': 'bar'}`, `{"foo":"bar"}`},
+		{"nested", "{\n  foo: 'bar', // trailing\n  baz: [1, 2, 3,],\n}", `{"foo":"bar","baz":[1,2,3]}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			strict, err := relaxedToStrictJSON([]byte(c.src))
+			if err != nil {
+				t.Fatalf("relaxedToStrictJSON(%q): unexpected error: %v", c.src, err)
+			}
+
+			var got, want interface{}
+			if err := json.Unmarshal(strict, &got); err != nil {
+				t.Fatalf("output %q is not valid JSON: %v", strict, err)
+			}
+
+			if err := json.Unmarshal([]byte(c.want), &want); err != nil {
+				t.Fatalf("bad test case, want %q is not valid JSON: %v", c.want, err)
+			}
+
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("relaxedToStrictJSON(%q) = %s, want %s", c.src, strict, c.want)
+			}
+		})
+	}
+}
+
+func TestRelaxedToStrictJSONUnterminatedComment(t *testing.T) {
+	if _, err := relaxedToStrictJSON([]byte("{/* oops")); err == nil {
+		t.Error("expected error for unterminated block comment, got nil")
+	}
+}
+
+func TestScanRelaxedString(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`'single'`, "single"},
+		{`"esc\"aped"`, `esc"aped`},
+		{`'esc\'aped'`, "esc'aped"},
+		{`"tab\there"`, "tab\there"},
+		{`"é"`, "é"},
+	}
+
+	for _, c := range cases {
+		got, next, err := scanRelaxedString([]byte(c.src), 0)
+		if err != nil {
+			t.Errorf("scanRelaxedString(%q): unexpected error: %v", c.src, err)
+			continue
+		}
+
+		if got != c.want {
+			t.Errorf("scanRelaxedString(%q) = %q, want %q", c.src, got, c.want)
+		}
+
+		if next != len(c.src) {
+			t.Errorf("scanRelaxedString(%q) consumed %d bytes, want %d", c.src, next, len(c.src))
+		}
+	}
+}
+
+func TestScanRelaxedStringUnterminated(t *testing.T) {
+	if _, _, err := scanRelaxedString([]byte(`"oops`), 0); err == nil {
+		t.Error("expected error for unterminated string, got nil")
+	}
+}